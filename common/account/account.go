@@ -1,15 +1,20 @@
 package account
 
 import (
+	"context"
 	"crypto"
 	"crypto/rsa"
-	"log"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
 
 	"github.com/go-acme/lego/certcrypto"
 	"github.com/go-acme/lego/lego"
 	"github.com/go-acme/lego/registration"
 	"github.com/google/uuid"
 	"github.com/secnex/certmanager/database"
+	"github.com/secnex/certmanager/logger"
 	"github.com/secnex/certmanager/models"
 	"github.com/secnex/certmanager/store"
 )
@@ -19,6 +24,8 @@ type Account struct {
 	PrivateKey         *crypto.PrivateKey
 	LetsEncryptAccount *LetsEncryptAccount
 	Client             *lego.Client
+
+	storage *store.Storage
 }
 
 type LetsEncryptAccount struct {
@@ -37,16 +44,29 @@ func (u *LetsEncryptAccount) GetPrivateKey() crypto.PrivateKey {
 	return u.Key
 }
 
-func NewAccount(email string, cnx *database.Database, storage *store.Storage) (*Account, error) {
+// NewAccount loads or creates an account against lego's default CA
+// directory (Let's Encrypt production). Use NewAccountWithDirectory to
+// target staging or a private ACME server.
+func NewAccount(ctx context.Context, email string, cnx *database.Database, storage *store.Storage) (*Account, error) {
+	return NewAccountWithDirectory(ctx, email, "", cnx, storage)
+}
+
+// NewAccountWithDirectory loads or creates an account scoped to a specific
+// ACME CA directory URL, so a single deployment can hold accounts against
+// Let's Encrypt production, staging, and private Pebble/step-ca instances
+// for the same email simultaneously.
+func NewAccountWithDirectory(ctx context.Context, email string, cadirURL string, cnx *database.Database, storage *store.Storage) (*Account, error) {
 	account := Account{
 		Account: models.Account{
-			Email: email,
+			Email:          email,
+			CADirectoryURL: cadirURL,
 		},
+		storage: storage,
 	}
-	// Check if account for this email already exists
+	// Check if account for this email and directory already exists
 	var existingAccount models.Account
-	log.Println("Checking if account for this email already exists")
-	err := cnx.Database.Where("email = ?", account.Email).First(&existingAccount).Error
+	logger.Println(ctx, "Checking if account for this email already exists")
+	err := cnx.Database.Where("email = ? AND ca_directory_url = ?", account.Email, account.CADirectoryURL).First(&existingAccount).Error
 	// If the account exists, is not a error, but an empty struct
 	if err != nil && err.Error() == "record not found" {
 		err = nil
@@ -75,16 +95,17 @@ func NewAccount(email string, cnx *database.Database, storage *store.Storage) (*
 		return &account, nil
 	}
 
-	log.Println("Account does not exist, creating new account!")
+	logger.Println(ctx, "Account does not exist, creating new account!")
 	var newAccount Account
 	newAccount.Email = account.Email
+	newAccount.CADirectoryURL = account.CADirectoryURL
 	err = cnx.Database.Create(&newAccount).Error
 	if err != nil {
 		return nil, err
 	}
 	account.ID = newAccount.ID
 
-	log.Println("Creating private key for account!")
+	logger.Println(ctx, "Creating private key for account!")
 	privateKey, err := account.CreatePrivateKey(storage)
 	if err != nil {
 		return nil, err
@@ -106,6 +127,9 @@ func NewAccount(email string, cnx *database.Database, storage *store.Storage) (*
 
 func (a *Account) CreateClient() (*lego.Client, error) {
 	config := lego.NewConfig(a.LetsEncryptAccount)
+	if a.CADirectoryURL != "" {
+		config.CADirURL = a.CADirectoryURL
+	}
 	client, err := lego.NewClient(config)
 	if err != nil {
 		return nil, err
@@ -128,10 +152,10 @@ func (a *Account) CreatePrivateKey(storage *store.Storage) (*crypto.PrivateKey,
 	return &accountPrivateKey, nil
 }
 
-func (a *Account) Create(cnx *database.Database, storage *store.Storage) (*Account, error) {
+func (a *Account) Create(ctx context.Context, cnx *database.Database, storage *store.Storage) (*Account, error) {
 	// Check if account for this email already exists
 	var existingAccount models.Account
-	log.Println("Checking if account for this email already exists")
+	logger.Println(ctx, "Checking if account for this email already exists")
 	err := cnx.Database.Where("email = ?", a.Email).First(&existingAccount).Error
 	if err != nil {
 		return nil, err
@@ -153,3 +177,100 @@ func (a *Account) GetAccount(cnx *database.Database, id string) (*Account, error
 	}
 	return &account, nil
 }
+
+// RegisterWithEAB registers the account using external account binding, as
+// required by ACME CAs like ZeroSSL or Google Trust Services.
+func (a *Account) RegisterWithEAB(kid, hmacKey string) error {
+	reg, err := a.Client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+		TermsOfServiceAgreed: true,
+		Kid:                  kid,
+		HmacEncoded:          hmacKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register account with external account binding: %w", err)
+	}
+
+	a.LetsEncryptAccount.Registration = reg
+	return nil
+}
+
+// RolloverKey performs an RFC 8555 §7.3.5 account key rollover: it POSTs a
+// doubly-signed JWS to the CA's keyChange endpoint so the server actually
+// accepts the new key, then swaps the active key in MinIO. Everything
+// after that POST succeeds is local bookkeeping only and is not rolled
+// back on failure - by that point the CA has already forgotten the old
+// key, so reverting to it locally would just strand the account on a key
+// the server no longer recognizes. The superseded key is kept under
+// accounts/<id>/keys/<timestamp>.pem for audit purposes.
+func (a *Account) RolloverKey(ctx context.Context) error {
+	logger.Println(ctx, "Rolling over account key for", a.Email)
+
+	// Registration is only populated by RegisterWithEAB; an ordinary account
+	// never gets it set locally, even though it is registered with the CA.
+	// Resolve it from the account's key instead of failing outright.
+	if a.LetsEncryptAccount.Registration == nil {
+		reg, err := a.Client.Registration.ResolveAccountByKey()
+		if err != nil {
+			return fmt.Errorf("account has no ACME registration to roll over: %w", err)
+		}
+		a.LetsEncryptAccount.Registration = reg
+	}
+
+	newKey, err := certcrypto.GeneratePrivateKey(certcrypto.RSA2048)
+	if err != nil {
+		return fmt.Errorf("failed to generate rollover key: %w", err)
+	}
+	rsaKey := newKey.(*rsa.PrivateKey)
+	oldKey := a.LetsEncryptAccount.Key
+
+	if err := rolloverKeyChange(a.CADirectoryURL, a.LetsEncryptAccount.Registration.URI, oldKey, rsaKey); err != nil {
+		return fmt.Errorf("failed to roll over account key: %w", err)
+	}
+
+	a.LetsEncryptAccount.Key = rsaKey
+	newClient, err := a.CreateClient()
+	if err != nil {
+		return fmt.Errorf("failed to build client for rolled over key (CA already accepted it): %w", err)
+	}
+	a.Client = newClient
+
+	if err := a.archiveKey(oldKey); err != nil {
+		return err
+	}
+
+	if err := a.storage.SavePrivateKey(a.ID.String(), rsaKey); err != nil {
+		return fmt.Errorf("failed to persist rolled over key: %w", err)
+	}
+
+	var privateKey crypto.PrivateKey = rsaKey
+	a.PrivateKey = &privateKey
+
+	return nil
+}
+
+// archiveKey keeps a superseded account key around under
+// accounts/<id>/keys/<timestamp>.pem instead of discarding it.
+func (a *Account) archiveKey(key *rsa.PrivateKey) error {
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal superseded key: %w", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})
+
+	timestamp := time.Now().UTC().Format("20060102150405")
+	path := fmt.Sprintf("accounts/%s/keys/%s.pem", a.ID, timestamp)
+	if err := a.storage.Save(path, pemBytes); err != nil {
+		return fmt.Errorf("failed to archive superseded key: %w", err)
+	}
+	return nil
+}
+
+// Deactivate tells the ACME server to deactivate this account. The account
+// row and archived keys are left in place for audit history.
+func (a *Account) Deactivate(ctx context.Context) error {
+	if err := a.Client.Registration.DeleteRegistration(); err != nil {
+		return fmt.Errorf("failed to deactivate account: %w", err)
+	}
+	logger.Println(ctx, "Deactivated account for", a.Email)
+	return nil
+}
@@ -0,0 +1,169 @@
+package account
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+
+	"github.com/go-acme/lego/lego"
+)
+
+// acmeDirectory is the subset of RFC 8555 §7.1.1's directory object this
+// package needs to drive a key rollover.
+type acmeDirectory struct {
+	NewNonce  string `json:"newNonce"`
+	KeyChange string `json:"keyChange"`
+}
+
+// jwsHeader is a JOSE protected header, restricted to the members a
+// key-change request needs.
+type jwsHeader struct {
+	Alg   string            `json:"alg"`
+	JWK   map[string]string `json:"jwk,omitempty"`
+	Kid   string            `json:"kid,omitempty"`
+	Nonce string            `json:"nonce,omitempty"`
+	URL   string            `json:"url"`
+}
+
+// jwsMessage is the flattened JWS JSON serialization ACME uses on the wire.
+type jwsMessage struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// rolloverKeyChange drives RFC 8555 §7.3.5 account key rollover: an inner
+// JWS, signed by the new key, names the account and the old key; that
+// inner JWS is then wrapped as the payload of an outer JWS signed by the
+// old key and POSTed to the CA's keyChange endpoint. Only once the CA
+// accepts this exchange does it actually recognize the new key - unlike
+// Registration.UpdateRegistration, which merely re-POSTs contact details
+// under whatever key is already attached to the client and never touches
+// the keyChange endpoint at all.
+func rolloverKeyChange(directoryURL, accountURL string, oldKey, newKey *rsa.PrivateKey) error {
+	if accountURL == "" {
+		return fmt.Errorf("account has no registration URI to roll over")
+	}
+	if directoryURL == "" {
+		directoryURL = lego.LEDirectoryProduction
+	}
+
+	dir, err := fetchDirectory(directoryURL)
+	if err != nil {
+		return err
+	}
+	if dir.KeyChange == "" {
+		return fmt.Errorf("CA directory at %s does not advertise a keyChange endpoint", directoryURL)
+	}
+
+	nonce, err := fetchNonce(dir.NewNonce)
+	if err != nil {
+		return err
+	}
+
+	innerPayload, err := json.Marshal(map[string]interface{}{
+		"account": accountURL,
+		"oldKey":  rsaJWK(&oldKey.PublicKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode key-change payload: %w", err)
+	}
+
+	// The inner JWS MUST NOT carry a nonce (RFC 8555 §7.3.5).
+	inner, err := signJWS(jwsHeader{Alg: "RS256", JWK: rsaJWK(&newKey.PublicKey), URL: dir.KeyChange}, innerPayload, newKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign inner key-change JWS: %w", err)
+	}
+	innerBytes, err := json.Marshal(inner)
+	if err != nil {
+		return fmt.Errorf("failed to encode inner key-change JWS: %w", err)
+	}
+
+	outer, err := signJWS(jwsHeader{Alg: "RS256", Kid: accountURL, Nonce: nonce, URL: dir.KeyChange}, innerBytes, oldKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign outer key-change JWS: %w", err)
+	}
+	outerBytes, err := json.Marshal(outer)
+	if err != nil {
+		return fmt.Errorf("failed to encode outer key-change JWS: %w", err)
+	}
+
+	resp, err := http.Post(dir.KeyChange, "application/jose+json", bytes.NewReader(outerBytes))
+	if err != nil {
+		return fmt.Errorf("failed to POST key change to %s: %w", dir.KeyChange, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("CA rejected key change (%s): %s", resp.Status, body)
+	}
+
+	return nil
+}
+
+func fetchDirectory(directoryURL string) (*acmeDirectory, error) {
+	resp, err := http.Get(directoryURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ACME directory from %s: %w", directoryURL, err)
+	}
+	defer resp.Body.Close()
+
+	var dir acmeDirectory
+	if err := json.NewDecoder(resp.Body).Decode(&dir); err != nil {
+		return nil, fmt.Errorf("failed to decode ACME directory from %s: %w", directoryURL, err)
+	}
+	return &dir, nil
+}
+
+func fetchNonce(newNonceURL string) (string, error) {
+	resp, err := http.Head(newNonceURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch a fresh nonce from %s: %w", newNonceURL, err)
+	}
+	defer resp.Body.Close()
+
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", fmt.Errorf("CA did not return a Replay-Nonce header from %s", newNonceURL)
+	}
+	return nonce, nil
+}
+
+func rsaJWK(key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func signJWS(header jwsHeader, payload []byte, key *rsa.PrivateKey) (*jwsMessage, error) {
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	protected := base64.RawURLEncoding.EncodeToString(headerBytes)
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := sha256.Sum256([]byte(protected + "." + encodedPayload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &jwsMessage{
+		Protected: protected,
+		Payload:   encodedPayload,
+		Signature: base64.RawURLEncoding.EncodeToString(signature),
+	}, nil
+}
@@ -1,13 +1,18 @@
 package certificate
 
 import (
+	"context"
+	"crypto/x509"
 	"fmt"
+	"time"
 
+	"github.com/go-acme/lego/certcrypto"
 	"github.com/go-acme/lego/certificate"
-	"github.com/go-acme/lego/challenge/dns01"
 	"github.com/go-acme/lego/challenge/http01"
 	"github.com/google/uuid"
 	"github.com/secnex/certmanager/common/account"
+	"github.com/secnex/certmanager/database"
+	"github.com/secnex/certmanager/logger"
 	"github.com/secnex/certmanager/models"
 	"github.com/secnex/certmanager/store"
 )
@@ -15,13 +20,43 @@ import (
 type ChallengeType string
 
 const (
-	ChallengeTypeHTTP ChallengeType = "http"
-	ChallengeTypeDNS  ChallengeType = "dns"
+	ChallengeTypeHTTP    ChallengeType = "http"
+	ChallengeTypeDNS     ChallengeType = "dns"
+	ChallengeTypeTLSALPN ChallengeType = "tls-alpn"
 )
 
+// DefaultDNSProviderRegistry is used whenever a CertificateConfig doesn't
+// supply its own registry, so callers that only care about "manual" DNS
+// don't need to wire one up themselves.
+var DefaultDNSProviderRegistry = NewDefaultDNSProviderRegistry()
+
 type CertificateConfig struct {
+	// ChallengeType is used when ChallengeTypes is empty, kept for callers
+	// that only ever configure a single challenge.
 	ChallengeType ChallengeType
-	DNSProvider   string // Provider name for DNS challenge
+	// ChallengeTypes, when set, are attempted in challengeTypeOrder until
+	// one succeeds, so operators get reproducible behavior on retries.
+	ChallengeTypes []ChallengeType
+	// ChallengeTimeout bounds how long a single challenge attempt may take
+	// before moving on to the next configured type. Zero means no timeout.
+	ChallengeTimeout time.Duration
+
+	DNSProvider string // Provider name for DNS challenge, e.g. "manual", "cloudflare", "route53"
+
+	// DB is consulted for the account's stored models.DNSCredential when
+	// DNSProvider is anything other than "manual".
+	DB *database.Database
+	// DNSRegistry overrides DefaultDNSProviderRegistry, mainly for tests.
+	DNSRegistry *DNSProviderRegistry
+	// DNSChallengeOptions controls the propagation strategy used when
+	// solving the DNS-01 challenge.
+	DNSChallengeOptions *DNSChallengeOptions
+
+	// DomainPolicy restricts which normalized domains may be requested.
+	DomainPolicy *DomainPolicy
+
+	// TLSALPNStore overrides DefaultTLSALPNStore, mainly for tests.
+	TLSALPNStore *TLSALPNStore
 }
 
 type Certificate struct {
@@ -31,28 +66,41 @@ type Certificate struct {
 	Config  *CertificateConfig
 }
 
-func NewCertificate(domains []string, account *account.Account, store *store.Storage) (*Certificate, error) {
-	return NewCertificateWithConfig(domains, account, store, &CertificateConfig{
+func NewCertificate(ctx context.Context, domains []string, account *account.Account, cnx *database.Database, store *store.Storage) (*Certificate, error) {
+	return NewCertificateWithConfig(ctx, domains, account, cnx, store, &CertificateConfig{
 		ChallengeType: ChallengeTypeHTTP,
 	})
 }
 
-func NewCertificateWithConfig(domains []string, account *account.Account, store *store.Storage, config *CertificateConfig) (*Certificate, error) {
+func NewCertificateWithConfig(ctx context.Context, domains []string, account *account.Account, cnx *database.Database, store *store.Storage, config *CertificateConfig) (*Certificate, error) {
+	normalized, err := normalizeDomains(domains, config.DomainPolicy)
+	if err != nil {
+		return nil, err
+	}
+
 	cert := &Certificate{
 		Certificate: models.Certificate{
-			ID:        uuid.New(),
-			Domains:   domains,
-			AccountID: account.ID,
+			ID:             uuid.New(),
+			Domains:        normalized.ASCII,
+			UnicodeDomains: normalized.Unicode,
+			AccountID:      account.ID,
+			Status:         models.CertificateStatusActive,
+			ChallengeType:  string(config.ChallengeType),
+			DNSProvider:    config.DNSProvider,
 		},
 		Account: account,
 		Config:  config,
 	}
 
-	err := cert.RequestNewCertificate()
+	err = cert.RequestNewCertificate(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := cnx.Database.Create(&cert.Certificate).Error; err != nil {
+		return nil, fmt.Errorf("failed to persist certificate record: %w", err)
+	}
+
 	err = cert.Save(store)
 	if err != nil {
 		return nil, err
@@ -61,33 +109,238 @@ func NewCertificateWithConfig(domains []string, account *account.Account, store
 	return cert, nil
 }
 
-func (c *Certificate) RequestNewCertificate() error {
-	switch c.Config.ChallengeType {
-	case ChallengeTypeHTTP:
-		err := c.setupHTTPChallenge()
+// RequestNewCertificate attempts the configured challenge types in
+// challengeTypeOrder (HTTP-01 -> TLS-ALPN-01 -> DNS-01), so a cert
+// configured with more than one challenge type behaves the same way on
+// every retry instead of depending on map/slice iteration order.
+func (c *Certificate) RequestNewCertificate(ctx context.Context) error {
+	ordered := orderChallengeTypes(c.configuredChallengeTypes())
+	if len(ordered) == 0 {
+		return fmt.Errorf("unsupported challenge type: %s", c.Config.ChallengeType)
+	}
+
+	var lastErr error
+	for _, challengeType := range ordered {
+		if err := c.setupChallenge(challengeType); err != nil {
+			lastErr = err
+			continue
+		}
+
+		logger.Printf(ctx, "certificate: requesting %s certificate for %v via %s challenge", c.ID, c.Domains, challengeType)
+		certs, err := c.obtainWithTimeout()
 		if err != nil {
+			lastErr = fmt.Errorf("%s challenge failed: %w", challengeType, err)
+			continue
+		}
+
+		c.Cert = certs
+		c.ChallengeType = string(challengeType)
+		return c.extractNotAfter()
+	}
+
+	return lastErr
+}
+
+// configuredChallengeTypes returns ChallengeTypes if set, falling back to
+// the single ChallengeType for callers that haven't migrated yet.
+func (c *Certificate) configuredChallengeTypes() []ChallengeType {
+	if len(c.Config.ChallengeTypes) > 0 {
+		return c.Config.ChallengeTypes
+	}
+	return []ChallengeType{c.Config.ChallengeType}
+}
+
+func (c *Certificate) setupChallenge(challengeType ChallengeType) error {
+	switch challengeType {
+	case ChallengeTypeHTTP:
+		if err := c.setupHTTPChallenge(); err != nil {
 			return fmt.Errorf("failed to setup HTTP challenge: %w", err)
 		}
 	case ChallengeTypeDNS:
-		err := c.setupDNSChallenge()
-		if err != nil {
+		if err := c.setupDNSChallenge(); err != nil {
 			return fmt.Errorf("failed to setup DNS challenge: %w", err)
 		}
+	case ChallengeTypeTLSALPN:
+		if err := c.setupTLSALPNChallenge(); err != nil {
+			return fmt.Errorf("failed to setup TLS-ALPN challenge: %w", err)
+		}
 	default:
-		return fmt.Errorf("unsupported challenge type: %s", c.Config.ChallengeType)
+		return fmt.Errorf("unsupported challenge type: %s", challengeType)
 	}
+	return nil
+}
 
+// obtainWithTimeout requests the certificate, bounding the attempt to
+// ChallengeTimeout when one is configured so a stuck challenge doesn't
+// block the next configured challenge type indefinitely.
+func (c *Certificate) obtainWithTimeout() (*certificate.Resource, error) {
 	request := certificate.ObtainRequest{
 		Domains: c.Domains,
 		Bundle:  true,
 	}
 
-	certs, err := c.Account.Client.Certificate.Obtain(request)
+	timeout := c.Config.ChallengeTimeout
+	if timeout <= 0 {
+		return c.Account.Client.Certificate.Obtain(request)
+	}
+
+	type obtainResult struct {
+		certs *certificate.Resource
+		err   error
+	}
+	done := make(chan obtainResult, 1)
+	go func() {
+		certs, err := c.Account.Client.Certificate.Obtain(request)
+		done <- obtainResult{certs, err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.certs, result.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("timed out after %s waiting for certificate issuance", timeout)
+	}
+}
+
+// extractNotAfter parses c.Cert.Certificate to keep models.Certificate.NotAfter
+// in sync with the leaf certificate, so the renewal scanner doesn't need to
+// re-parse PEM data itself.
+func (c *Certificate) extractNotAfter() error {
+	leaf, err := certcrypto.ParsePEMCertificate(c.Cert.Certificate)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to parse issued certificate: %w", err)
+	}
+	c.NotAfter = leaf.NotAfter
+	return nil
+}
+
+// serial returns the hex-encoded serial number of the currently stored leaf
+// certificate, used to namespace archived copies.
+func (c *Certificate) serial() (string, error) {
+	leaf, err := certcrypto.ParsePEMCertificate(c.Cert.Certificate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse certificate for serial: %w", err)
 	}
+	return fmt.Sprintf("%x", leaf.SerialNumber), nil
+}
+
+// Renew re-runs the configured challenge and obtains a fresh certificate for
+// the same domains, archiving the superseded certificate in MinIO before the
+// new one is saved.
+func (c *Certificate) Renew(ctx context.Context, cnx *database.Database, store *store.Storage) error {
+	previous := c.Cert
+
+	logger.Printf(ctx, "certificate: renewing certificate %s", c.ID)
+	c.Status = models.CertificateStatusRenewing
+	if err := cnx.Database.Save(&c.Certificate).Error; err != nil {
+		return fmt.Errorf("failed to mark certificate as renewing: %w", err)
+	}
+
+	if err := c.RequestNewCertificate(ctx); err != nil {
+		c.Status = models.CertificateStatusActive
+		cnx.Database.Save(&c.Certificate)
+		return fmt.Errorf("failed to renew certificate: %w", err)
+	}
+
+	if previous != nil {
+		logger.Printf(ctx, "certificate: archiving superseded certificate %s", c.ID)
+		if err := c.archive(store, previous.Certificate); err != nil {
+			return err
+		}
+	}
+
+	c.Status = models.CertificateStatusActive
+	if err := cnx.Database.Save(&c.Certificate).Error; err != nil {
+		return fmt.Errorf("failed to persist renewed certificate: %w", err)
+	}
+
+	logger.Printf(ctx, "certificate: persisted renewed certificate %s", c.ID)
+	return c.Save(store)
+}
+
+// RenewFromCSR renews the certificate from a caller-supplied CSR instead of
+// generating a new keypair, so users with HSM-managed keys can rotate certs
+// without ever exporting the private key.
+func (c *Certificate) RenewFromCSR(ctx context.Context, csr *x509.CertificateRequest, cnx *database.Database, store *store.Storage) error {
+	previous := c.Cert
 
+	logger.Printf(ctx, "certificate: renewing certificate %s from CSR", c.ID)
+	c.Status = models.CertificateStatusRenewing
+	if err := cnx.Database.Save(&c.Certificate).Error; err != nil {
+		return fmt.Errorf("failed to mark certificate as renewing: %w", err)
+	}
+
+	request := certificate.ObtainForCSRRequest{
+		CSR:    csr,
+		Bundle: true,
+	}
+
+	certs, err := c.Account.Client.Certificate.ObtainForCSR(request)
+	if err != nil {
+		c.Status = models.CertificateStatusActive
+		cnx.Database.Save(&c.Certificate)
+		return fmt.Errorf("failed to renew certificate from CSR: %w", err)
+	}
 	c.Cert = certs
+
+	if err := c.extractNotAfter(); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		logger.Printf(ctx, "certificate: archiving superseded certificate %s", c.ID)
+		if err := c.archive(store, previous.Certificate); err != nil {
+			return err
+		}
+	}
+
+	c.Status = models.CertificateStatusActive
+	if err := cnx.Database.Save(&c.Certificate).Error; err != nil {
+		return fmt.Errorf("failed to persist renewed certificate: %w", err)
+	}
+
+	logger.Printf(ctx, "certificate: persisted renewed certificate %s", c.ID)
+	return c.saveCertificateBody(store)
+}
+
+// Revoke revokes the certificate with the ACME server, archives it under
+// archive/revoked/, and marks the DB row revoked.
+func (c *Certificate) Revoke(ctx context.Context, cnx *database.Database, store *store.Storage) error {
+	logger.Printf(ctx, "certificate: revoking certificate %s", c.ID)
+	if err := c.Account.Client.Certificate.Revoke(c.Cert.Certificate); err != nil {
+		return fmt.Errorf("failed to revoke certificate: %w", err)
+	}
+
+	serial, err := c.serial()
+	if err != nil {
+		return err
+	}
+	if err := store.ArchiveRevokedCertificate(c.ID.String(), serial, c.Cert.Certificate); err != nil {
+		return fmt.Errorf("failed to archive revoked certificate: %w", err)
+	}
+
+	now := time.Now()
+	c.RevokedAt = &now
+	c.Status = models.CertificateStatusRevoked
+	if err := cnx.Database.Save(&c.Certificate).Error; err != nil {
+		return fmt.Errorf("failed to persist revoked certificate: %w", err)
+	}
+
+	logger.Printf(ctx, "certificate: persisted revoked certificate %s", c.ID)
+	return nil
+}
+
+// archive parses the superseded certificate's serial and moves it under
+// certs/archive/<id>/<serial>.pem.
+func (c *Certificate) archive(store *store.Storage, pemBytes []byte) error {
+	leaf, err := certcrypto.ParsePEMCertificate(pemBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse superseded certificate: %w", err)
+	}
+	serial := fmt.Sprintf("%x", leaf.SerialNumber)
+	if err := store.ArchiveCertificate(c.ID.String(), serial, pemBytes); err != nil {
+		return fmt.Errorf("failed to archive superseded certificate: %w", err)
+	}
 	return nil
 }
 
@@ -97,31 +350,99 @@ func (c *Certificate) setupHTTPChallenge() error {
 }
 
 func (c *Certificate) setupDNSChallenge() error {
-	// Use manual DNS provider - requires user to manually set DNS records
-	provider, err := dns01.NewDNSProviderManual()
+	registry := c.Config.DNSRegistry
+	if registry == nil {
+		registry = DefaultDNSProviderRegistry
+	}
+
+	providerName := c.Config.DNSProvider
+	if providerName == "" {
+		providerName = "manual"
+	}
+
+	config := map[string]string{}
+	if providerName != "manual" {
+		credential, err := LoadDNSCredential(c.Config.DB, c.Account.ID, providerName)
+		if err != nil {
+			return err
+		}
+		config = credential
+	}
+
+	provider, err := registry.New(providerName, config)
 	if err != nil {
-		return fmt.Errorf("failed to create manual DNS provider: %w", err)
+		return fmt.Errorf("failed to create %s DNS provider: %w", providerName, err)
 	}
-	return c.Account.Client.Challenge.SetDNS01Provider(provider)
+	provider = applySequentialSolveInterval(provider, c.Config.DNSChallengeOptions)
+
+	options := applyDNSChallengeOptions(c.Config.DNSChallengeOptions)
+	return c.Account.Client.Challenge.SetDNS01Provider(provider, options...)
 }
 
 func (c *Certificate) Save(store *store.Storage) error {
 	return store.SaveCertificate(c.ID.String(), c.Cert)
 }
 
+// saveCertificateBody persists only the certificate PEM, not the private
+// key. Use it in place of Save whenever c.Cert.PrivateKey is empty, e.g.
+// after RenewFromCSR, where the key never left the caller and so must not
+// overwrite the key already stored under the certificate's id.
+func (c *Certificate) saveCertificateBody(store *store.Storage) error {
+	return store.SaveCertificateBody(c.ID.String(), c.Cert.Certificate)
+}
+
+// LoadCertificate reconstructs a Certificate from its Postgres row and the
+// PEM bundle stored in MinIO, wiring up a fresh ACME client for the owning
+// account so it can be renewed or revoked.
+func LoadCertificate(ctx context.Context, id uuid.UUID, cnx *database.Database, storage *store.Storage) (*Certificate, error) {
+	var row models.Certificate
+	if err := cnx.Database.Where("id = ?", id).First(&row).Error; err != nil {
+		return nil, fmt.Errorf("failed to load certificate %s: %w", id, err)
+	}
+
+	var accountRow models.Account
+	if err := cnx.Database.Where("id = ?", row.AccountID).First(&accountRow).Error; err != nil {
+		return nil, fmt.Errorf("failed to load account for certificate %s: %w", id, err)
+	}
+
+	acct, err := account.NewAccountWithDirectory(ctx, accountRow.Email, accountRow.CADirectoryURL, cnx, storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account for certificate %s: %w", id, err)
+	}
+
+	pemBytes, err := storage.Read(id.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate bundle for %s: %w", id, err)
+	}
+
+	challengeType := ChallengeType(row.ChallengeType)
+	if challengeType == "" {
+		challengeType = ChallengeTypeHTTP
+	}
+
+	return &Certificate{
+		Certificate: row,
+		Account:     acct,
+		Cert:        &certificate.Resource{Certificate: pemBytes},
+		Config: &CertificateConfig{
+			ChallengeType: challengeType,
+			DNSProvider:   row.DNSProvider,
+			DB:            cnx,
+		},
+	}, nil
+}
+
 // GetSupportedChallengeTypes returns a list of supported challenge types
 func GetSupportedChallengeTypes() []string {
-	return []string{"http", "dns"}
+	return []string{"http", "dns", "tls-alpn"}
 }
 
-// ValidateDNSProviderConfig validates DNS provider configuration
+// ValidateDNSProviderConfig validates DNS provider configuration against the
+// default registry of automated providers, plus "manual".
 func ValidateDNSProviderConfig(provider string) error {
-	// For manual DNS, no specific environment variables are required
-	// The user will need to manually set DNS records as prompted
-	if provider == "manual" {
+	if DefaultDNSProviderRegistry.IsSupported(provider) {
 		return nil
 	}
 
-	// For future automated DNS providers, add validation here
-	return fmt.Errorf("DNS provider '%s' not supported yet. Use 'manual' for manual DNS record setup", provider)
+	return fmt.Errorf("DNS provider '%s' not supported. Use 'manual' or one of the registered automated providers", provider)
 }
@@ -0,0 +1,23 @@
+package certificate
+
+// challengeTypeOrder is the canonical, reproducible order challenge types
+// are attempted in when more than one is configured for a certificate.
+var challengeTypeOrder = []ChallengeType{ChallengeTypeHTTP, ChallengeTypeTLSALPN, ChallengeTypeDNS}
+
+// orderChallengeTypes returns the requested challenge types following
+// challengeTypeOrder, so retries behave the same way regardless of the
+// order they were configured in.
+func orderChallengeTypes(requested []ChallengeType) []ChallengeType {
+	requestedSet := make(map[ChallengeType]bool, len(requested))
+	for _, t := range requested {
+		requestedSet[t] = true
+	}
+
+	ordered := make([]ChallengeType, 0, len(requested))
+	for _, t := range challengeTypeOrder {
+		if requestedSet[t] {
+			ordered = append(ordered, t)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,215 @@
+package certificate
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-acme/lego/challenge"
+	"github.com/go-acme/lego/challenge/dns01"
+	"github.com/go-acme/lego/providers/dns/azuredns"
+	"github.com/go-acme/lego/providers/dns/cloudflare"
+	"github.com/go-acme/lego/providers/dns/dnsimple"
+	"github.com/go-acme/lego/providers/dns/exoscale"
+	"github.com/go-acme/lego/providers/dns/gandi"
+	"github.com/go-acme/lego/providers/dns/route53"
+	"github.com/google/uuid"
+	"github.com/secnex/certmanager/database"
+	"github.com/secnex/certmanager/models"
+)
+
+// DNSProviderFactory builds a lego challenge.Provider from the credentials
+// stored for an account, instead of the provider reading process-wide
+// environment variables.
+type DNSProviderFactory interface {
+	New(config map[string]string) (challenge.Provider, error)
+}
+
+// DNSChallengeOptions controls how the DNS-01 challenge is solved once the
+// provider is created, so operators can pick a propagation strategy per
+// certificate.
+type DNSChallengeOptions struct {
+	RecursiveNameservers           []string
+	DisableCompletePropagation     bool
+	SequentialSolveIntervalSeconds int
+}
+
+// DNSProviderRegistry maps a provider name (as stored in
+// models.DNSCredential.Provider) to the factory that knows how to build it.
+type DNSProviderRegistry struct {
+	factories map[string]DNSProviderFactory
+}
+
+// NewDNSProviderRegistry returns an empty registry. Use
+// NewDefaultDNSProviderRegistry to get one pre-populated with lego's
+// automated providers.
+func NewDNSProviderRegistry() *DNSProviderRegistry {
+	return &DNSProviderRegistry{factories: make(map[string]DNSProviderFactory)}
+}
+
+// NewDefaultDNSProviderRegistry wires in the automated DNS providers
+// supported out of the box, in addition to "manual".
+func NewDefaultDNSProviderRegistry() *DNSProviderRegistry {
+	registry := NewDNSProviderRegistry()
+	registry.Register("cloudflare", cloudflareFactory{})
+	registry.Register("route53", route53Factory{})
+	registry.Register("azure", azureFactory{})
+	registry.Register("dnsimple", dnsimpleFactory{})
+	registry.Register("gandi", gandiFactory{})
+	registry.Register("exoscale", exoscaleFactory{})
+	return registry
+}
+
+// Register adds or replaces the factory for a provider name.
+func (r *DNSProviderRegistry) Register(name string, factory DNSProviderFactory) {
+	r.factories[name] = factory
+}
+
+// IsSupported reports whether a provider name has a registered factory, or
+// is the built-in "manual" provider.
+func (r *DNSProviderRegistry) IsSupported(name string) bool {
+	if name == "manual" {
+		return true
+	}
+	_, ok := r.factories[name]
+	return ok
+}
+
+// New builds the challenge.Provider for name using config, or the manual
+// provider if name is "manual".
+func (r *DNSProviderRegistry) New(name string, config map[string]string) (challenge.Provider, error) {
+	if name == "manual" {
+		return dns01.NewDNSProviderManual()
+	}
+
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("DNS provider '%s' is not registered", name)
+	}
+	return factory.New(config)
+}
+
+// LoadDNSCredential fetches the stored credential config for an account and
+// provider at challenge time, rather than relying on process-wide env vars.
+func LoadDNSCredential(cnx *database.Database, accountID uuid.UUID, provider string) (map[string]string, error) {
+	var credential models.DNSCredential
+	err := cnx.Database.Where("account_id = ? AND provider = ?", accountID, provider).First(&credential).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load DNS credential for provider '%s': %w", provider, err)
+	}
+	return credential.Config, nil
+}
+
+type cloudflareFactory struct{}
+
+func (cloudflareFactory) New(config map[string]string) (challenge.Provider, error) {
+	cfg := cloudflare.NewDefaultConfig()
+	cfg.AuthToken = config["api_token"]
+	cfg.AuthEmail = config["email"]
+	cfg.AuthKey = config["api_key"]
+	return cloudflare.NewDNSProviderConfig(cfg)
+}
+
+type route53Factory struct{}
+
+func (route53Factory) New(config map[string]string) (challenge.Provider, error) {
+	cfg := route53.NewDefaultConfig()
+	cfg.AccessKeyID = config["access_key_id"]
+	cfg.SecretAccessKey = config["secret_access_key"]
+	cfg.Region = config["region"]
+	cfg.HostedZoneID = config["hosted_zone_id"]
+	return route53.NewDNSProviderConfig(cfg)
+}
+
+type azureFactory struct{}
+
+func (azureFactory) New(config map[string]string) (challenge.Provider, error) {
+	cfg := azuredns.NewDefaultConfig()
+	cfg.SubscriptionID = config["subscription_id"]
+	cfg.ResourceGroup = config["resource_group"]
+	cfg.TenantID = config["tenant_id"]
+	cfg.ClientID = config["client_id"]
+	cfg.ClientSecret = config["client_secret"]
+	return azuredns.NewDNSProviderConfig(cfg)
+}
+
+type dnsimpleFactory struct{}
+
+func (dnsimpleFactory) New(config map[string]string) (challenge.Provider, error) {
+	cfg := dnsimple.NewDefaultConfig()
+	cfg.AuthToken = config["api_token"]
+	cfg.BaseURL = config["base_url"]
+	return dnsimple.NewDNSProviderConfig(cfg)
+}
+
+type gandiFactory struct{}
+
+func (gandiFactory) New(config map[string]string) (challenge.Provider, error) {
+	cfg := gandi.NewDefaultConfig()
+	cfg.APIKey = config["api_key"]
+	return gandi.NewDNSProviderConfig(cfg)
+}
+
+type exoscaleFactory struct{}
+
+func (exoscaleFactory) New(config map[string]string) (challenge.Provider, error) {
+	cfg := exoscale.NewDefaultConfig()
+	cfg.APIKey = config["api_key"]
+	cfg.APISecret = config["api_secret"]
+	cfg.Endpoint = config["endpoint"]
+	return exoscale.NewDNSProviderConfig(cfg)
+}
+
+// applyDNSChallengeOptions turns the propagation strategy requested for this
+// certificate into lego's dns01 challenge options.
+func applyDNSChallengeOptions(opts *DNSChallengeOptions) []dns01.ChallengeOption {
+	if opts == nil {
+		return nil
+	}
+
+	var options []dns01.ChallengeOption
+	if len(opts.RecursiveNameservers) > 0 {
+		options = append(options, dns01.AddRecursiveNameservers(opts.RecursiveNameservers))
+	}
+	if opts.DisableCompletePropagation {
+		options = append(options, dns01.DisableCompletePropagationRequirement())
+	}
+	return options
+}
+
+// applySequentialSolveInterval wraps provider so that, on a certificate
+// covering multiple domains, each domain's DNS-01 challenge is presented at
+// least SequentialSolveIntervalSeconds apart instead of back-to-back,
+// letting an operator throttle how hard a multi-domain order hits their DNS
+// provider's API.
+func applySequentialSolveInterval(provider challenge.Provider, opts *DNSChallengeOptions) challenge.Provider {
+	if opts == nil || opts.SequentialSolveIntervalSeconds <= 0 {
+		return provider
+	}
+	return &sequentialDelayProvider{
+		Provider: provider,
+		interval: time.Duration(opts.SequentialSolveIntervalSeconds) * time.Second,
+	}
+}
+
+// sequentialDelayProvider delays every Present call after the first by
+// interval, so lego's per-domain challenge loop solves domains serially
+// with a deliberate gap between them rather than as fast as it can.
+type sequentialDelayProvider struct {
+	challenge.Provider
+	interval time.Duration
+
+	mu      sync.Mutex
+	started bool
+}
+
+func (p *sequentialDelayProvider) Present(domain, token, keyAuth string) error {
+	p.mu.Lock()
+	if p.started {
+		time.Sleep(p.interval)
+	}
+	p.started = true
+	p.mu.Unlock()
+
+	return p.Provider.Present(domain, token, keyAuth)
+}
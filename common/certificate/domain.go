@@ -0,0 +1,101 @@
+package certificate
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidDomain is returned when a user-supplied domain fails
+// normalization, identifying which input index was at fault so callers can
+// surface it back to the user without re-scanning the original slice.
+type ErrInvalidDomain struct {
+	Index  int
+	Domain string
+	Reason string
+}
+
+func (e *ErrInvalidDomain) Error() string {
+	return fmt.Sprintf("invalid domain at index %d (%q): %s", e.Index, e.Domain, e.Reason)
+}
+
+// DomainPolicy is a configurable allow/deny list applied to normalized
+// (ASCII) domains before they are sent to the ACME server.
+type DomainPolicy struct {
+	Allow []string // if non-empty, only these domains (or their subdomains) are permitted
+	Deny  []string
+}
+
+// Allows reports whether domain passes the policy.
+func (p *DomainPolicy) Allows(domain string) bool {
+	for _, d := range p.Deny {
+		if matchesDomainPattern(domain, d) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, d := range p.Allow {
+		if matchesDomainPattern(domain, d) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesDomainPattern(domain, pattern string) bool {
+	return domain == pattern || strings.HasSuffix(domain, "."+pattern)
+}
+
+// NormalizedDomains holds the ASCII (A-label) and original Unicode forms of
+// a set of domains, in the same order they were supplied.
+type NormalizedDomains struct {
+	ASCII   []string
+	Unicode []string
+}
+
+var idnaProfile = idna.New(idna.MapForLookup(), idna.BidiRule())
+
+// normalizeDomains converts every domain to its ASCII/punycode form via
+// golang.org/x/net/idna before it reaches ACME, rejecting mixed-case and
+// trailing-dot inputs and validating against an optional allow/deny policy.
+func normalizeDomains(domains []string, policy *DomainPolicy) (*NormalizedDomains, error) {
+	result := &NormalizedDomains{
+		ASCII:   make([]string, len(domains)),
+		Unicode: make([]string, len(domains)),
+	}
+
+	for i, domain := range domains {
+		if domain != strings.ToLower(domain) {
+			return nil, &ErrInvalidDomain{Index: i, Domain: domain, Reason: "domain must be lowercase"}
+		}
+		if strings.HasSuffix(domain, ".") {
+			return nil, &ErrInvalidDomain{Index: i, Domain: domain, Reason: "domain must not have a trailing dot"}
+		}
+
+		// idnaProfile.ToASCII rejects the "*" rune outright, so a wildcard
+		// domain must have its "*." label stripped before conversion and
+		// re-prepended afterwards instead of being fed to IDNA whole.
+		label, base := "", domain
+		if strings.HasPrefix(domain, "*.") {
+			label, base = "*.", strings.TrimPrefix(domain, "*.")
+		}
+
+		ascii, err := idnaProfile.ToASCII(base)
+		if err != nil {
+			return nil, &ErrInvalidDomain{Index: i, Domain: domain, Reason: fmt.Sprintf("invalid punycode: %s", err)}
+		}
+		ascii = label + ascii
+
+		if policy != nil && !policy.Allows(ascii) {
+			return nil, &ErrInvalidDomain{Index: i, Domain: domain, Reason: "domain is not allowed by policy"}
+		}
+
+		result.ASCII[i] = ascii
+		result.Unicode[i] = domain
+	}
+
+	return result, nil
+}
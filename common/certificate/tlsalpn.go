@@ -0,0 +1,70 @@
+package certificate
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"github.com/go-acme/lego/challenge/tlsalpn01"
+)
+
+// DefaultTLSALPNStore is used whenever a CertificateConfig doesn't supply
+// its own store, mirroring DefaultDNSProviderRegistry.
+var DefaultTLSALPNStore = NewTLSALPNStore()
+
+// TLSALPNStore holds in-flight tls-alpn-01 challenge key authorizations so
+// the API server's existing :443 listener can answer acme-tls/1 handshakes
+// itself instead of lego's ProviderServer binding a second listener.
+//
+// It implements lego's challenge.Provider interface (Present/CleanUp), so
+// it can be registered directly with Client.Challenge.SetTLSALPN01Provider.
+type TLSALPNStore struct {
+	mu      sync.Mutex
+	keyAuth map[string]string // domain -> keyAuth
+}
+
+// NewTLSALPNStore returns an empty store.
+func NewTLSALPNStore() *TLSALPNStore {
+	return &TLSALPNStore{keyAuth: make(map[string]string)}
+}
+
+// Present records the key authorization the ACME server expects to see
+// presented back over TLS-ALPN for domain.
+func (s *TLSALPNStore) Present(domain, token, keyAuth string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyAuth[domain] = keyAuth
+	return nil
+}
+
+// CleanUp removes the key authorization once the challenge has resolved.
+func (s *TLSALPNStore) CleanUp(domain, token, keyAuth string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keyAuth, domain)
+	return nil
+}
+
+// ChallengeCertificate returns the self-signed acme-tls/1 certificate to
+// present for domain, if a challenge is currently in flight for it.
+func (s *TLSALPNStore) ChallengeCertificate(domain string) (*tls.Certificate, bool) {
+	s.mu.Lock()
+	keyAuth, ok := s.keyAuth[domain]
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	cert, _, err := tlsalpn01.ChallengeCert(domain, keyAuth)
+	if err != nil {
+		return nil, false
+	}
+	return cert, true
+}
+
+func (c *Certificate) setupTLSALPNChallenge() error {
+	store := c.Config.TLSALPNStore
+	if store == nil {
+		store = DefaultTLSALPNStore
+	}
+	return c.Account.Client.Challenge.SetTLSALPN01Provider(store)
+}
@@ -58,7 +58,7 @@ func NewConnection(host string, port int, user string, password string, database
 		log.Fatal(err)
 	}
 
-	db.AutoMigrate(models.Account{})
+	db.AutoMigrate(models.Account{}, models.DNSCredential{}, models.Certificate{})
 
 	return &Database{
 		Connection: connection,
@@ -0,0 +1,38 @@
+package logger
+
+import "context"
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	entryKey
+)
+
+// WithRequestID attaches requestID to ctx so certificate/account/manager
+// log calls can tag their output with the same ID an operator sees in the
+// HTTP access log.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID attached by WithRequestID, or
+// "" if none is set.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// withEntry attaches the in-flight HTTPLogEntry to ctx so downstream
+// middleware (e.g. auth) can annotate it with fields only known deeper in
+// the handler chain, such as the authenticated user.
+func withEntry(ctx context.Context, entry *HTTPLogEntry) context.Context {
+	return context.WithValue(ctx, entryKey, entry)
+}
+
+// EntryFromContext returns the in-flight HTTPLogEntry for ctx, if
+// LogHTTPRequest is wrapping the current handler chain.
+func EntryFromContext(ctx context.Context) *HTTPLogEntry {
+	entry, _ := ctx.Value(entryKey).(*HTTPLogEntry)
+	return entry
+}
@@ -1,28 +1,41 @@
 package logger
 
 import (
+	"crypto/tls"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 )
 
 type HTTPLogEntry struct {
-	RemoteAddr   string
-	RequestTime  time.Time
-	Method       string
-	Path         string
-	Protocol     string
-	StatusCode   int
-	ResponseSize int64
-	ResponseTime time.Duration
-	UserAgent    string
-	Referer      string
+	RequestID     string
+	RemoteAddr    string
+	RequestTime   time.Time
+	Method        string
+	Path          string
+	RouteTemplate string
+	Protocol      string
+	StatusCode    int
+	ResponseSize  int64
+	ResponseTime  time.Duration
+	UserAgent     string
+	Referer       string
+
+	UserID    string
+	UserEmail string
+
+	TLSVersion  string
+	CipherSuite string
+	ServerName  string
 }
 
 func FormatHTTPLog(entry HTTPLogEntry) string {
-	return fmt.Sprintf("%s - - \"%s %s %s\" %d %d \"%s\" \"%s\" %.3f",
+	return fmt.Sprintf("%s [%s] - - \"%s %s %s\" %d %d \"%s\" \"%s\" %.3f",
 		entry.RemoteAddr,
+		entry.RequestID,
 		entry.Method,
 		entry.Path,
 		entry.Protocol,
@@ -34,10 +47,42 @@ func FormatHTTPLog(entry HTTPLogEntry) string {
 	)
 }
 
+// LogHTTPRequest records one HTTPLogEntry per request to DefaultSink. It
+// must be registered via Router.Use (not as an outer http.Handler wrapper)
+// so mux.CurrentRoute resolves to the matched route, and so the entry it
+// attaches to the request context is visible to middleware further down
+// the chain, such as AuthMiddleware.
 func LogHTTPRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		entry := &HTTPLogEntry{
+			RequestID:   requestID,
+			RemoteAddr:  r.RemoteAddr,
+			RequestTime: start,
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Protocol:    r.Proto,
+			UserAgent:   r.UserAgent(),
+			Referer:     r.Referer(),
+		}
+
+		if r.TLS != nil {
+			entry.TLSVersion = tlsVersionName(r.TLS.Version)
+			entry.CipherSuite = tls.CipherSuiteName(r.TLS.CipherSuite)
+			entry.ServerName = r.TLS.ServerName
+		}
+
+		ctx := WithRequestID(r.Context(), requestID)
+		ctx = withEntry(ctx, entry)
+		r = r.WithContext(ctx)
+
 		rw := &responseWriter{
 			ResponseWriter: w,
 			statusCode:     http.StatusOK,
@@ -45,22 +90,34 @@ func LogHTTPRequest(next http.Handler) http.Handler {
 
 		next.ServeHTTP(rw, r)
 
-		entry := HTTPLogEntry{
-			RemoteAddr:   r.RemoteAddr,
-			Method:       r.Method,
-			Path:         r.URL.Path,
-			Protocol:     r.Proto,
-			StatusCode:   rw.statusCode,
-			ResponseSize: rw.size,
-			ResponseTime: time.Since(start),
-			UserAgent:    r.UserAgent(),
-			Referer:      r.Referer(),
+		entry.StatusCode = rw.statusCode
+		entry.ResponseSize = rw.size
+		entry.ResponseTime = time.Since(start)
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				entry.RouteTemplate = tmpl
+			}
 		}
 
-		log.Println(FormatHTTPLog(entry))
+		DefaultSink.Log(*entry)
 	})
 }
 
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	default:
+		return fmt.Sprintf("0x%04x", version)
+	}
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"context"
+	"log"
+)
+
+// Printf logs like log.Printf, prefixing the message with the request ID
+// attached to ctx (via WithRequestID), if any, so an operator can grep one
+// ID across HTTP, ACME, and storage layers.
+func Printf(ctx context.Context, format string, args ...interface{}) {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		log.Printf(format, args...)
+		return
+	}
+	log.Printf("[%s] "+format, append([]interface{}{requestID}, args...)...)
+}
+
+// Println is the Println counterpart to Printf.
+func Println(ctx context.Context, args ...interface{}) {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		log.Println(args...)
+		return
+	}
+	log.Println(append([]interface{}{"[" + requestID + "]"}, args...)...)
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// Sink is how an access log entry leaves the process. LogHTTPRequest calls
+// Log once per request; a Sink implementation decides where that goes.
+type Sink interface {
+	Log(entry HTTPLogEntry)
+}
+
+// DefaultSink is used by LogHTTPRequest when none is configured. Set it
+// once at startup (e.g. to NewJSONSink(os.Stdout)) to change how access
+// logs are emitted without touching call sites.
+var DefaultSink Sink = StdoutSink{}
+
+// StdoutSink renders entries as FormatHTTPLog's Apache-combined-ish text
+// line, with the request ID in brackets after the remote address.
+type StdoutSink struct{}
+
+func (StdoutSink) Log(entry HTTPLogEntry) {
+	log.Println(FormatHTTPLog(entry))
+}
+
+// JSONSink writes one JSON object per line, suitable for ingestion by a log
+// shipper.
+type JSONSink struct {
+	Writer io.Writer
+}
+
+// NewJSONSink returns a JSONSink writing to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{Writer: w}
+}
+
+func (s *JSONSink) Log(entry HTTPLogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("logger: failed to marshal access log entry: %v", err)
+		return
+	}
+	fmt.Fprintln(s.Writer, string(data))
+}
+
+// OTLPSink exports entries as JSON to an OTLP/HTTP-compatible log
+// collector endpoint.
+type OTLPSink struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewOTLPSink returns an OTLPSink posting to endpoint using http.DefaultClient.
+func NewOTLPSink(endpoint string) *OTLPSink {
+	return &OTLPSink{Endpoint: endpoint, Client: http.DefaultClient}
+}
+
+func (s *OTLPSink) Log(entry HTTPLogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("logger: failed to marshal access log entry for OTLP export: %v", err)
+		return
+	}
+
+	// Wrap the entry as the body of a single OTLP/JSON log record.
+	record := map[string]interface{}{
+		"timeUnixNano": fmt.Sprintf("%d", entry.RequestTime.UnixNano()),
+		"body":         map[string]string{"stringValue": string(data)},
+	}
+	data, err = json.Marshal(map[string]interface{}{
+		"resourceLogs": []interface{}{map[string]interface{}{
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"logRecords": []interface{}{record},
+			}},
+		}},
+	})
+	if err != nil {
+		log.Printf("logger: failed to marshal OTLP log request: %v", err)
+		return
+	}
+
+	resp, err := s.Client.Post(s.Endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("logger: failed to export access log entry to %s: %v", s.Endpoint, err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -18,6 +18,9 @@ func NewCertManager(database *database.Database, storage *store.Storage) *CertMa
 }
 
 func (m *CertManager) RunServer() {
+	renewer := manager.NewRenewer(m.Manager)
+	go renewer.Start()
+
 	apiServer := server.NewApiServer(nil, nil, m.Manager)
 	apiServer.Start()
 }
@@ -1,15 +1,28 @@
 package manager
 
 import (
+	"context"
+	"crypto/x509"
+
+	"github.com/google/uuid"
 	"github.com/secnex/certmanager/common/account"
 	"github.com/secnex/certmanager/common/certificate"
 	"github.com/secnex/certmanager/database"
+	"github.com/secnex/certmanager/models"
 	"github.com/secnex/certmanager/store"
 )
 
+// CertCache lets a caller that caches parsed certificates (e.g. a TLS SNI
+// resolver) find out about a renewal or revocation, instead of keeping a
+// stale or revoked certificate cached until an unrelated eviction.
+type CertCache interface {
+	Invalidate(domains ...string)
+}
+
 type Manager struct {
-	Database database.Database
-	Storage  *store.Storage
+	Database  database.Database
+	Storage   *store.Storage
+	CertCache CertCache
 }
 
 func NewManager(database *database.Database, storage *store.Storage) *Manager {
@@ -19,10 +32,67 @@ func NewManager(database *database.Database, storage *store.Storage) *Manager {
 	}
 }
 
-func (m *Manager) NewAccount(email string) (*account.Account, error) {
-	return account.NewAccount(email, &m.Database, m.Storage)
+func (m *Manager) NewAccount(ctx context.Context, email string) (*account.Account, error) {
+	return account.NewAccount(ctx, email, &m.Database, m.Storage)
+}
+
+func (m *Manager) NewCertificate(ctx context.Context, domains []string, account *account.Account) (*certificate.Certificate, error) {
+	return certificate.NewCertificate(ctx, domains, account, &m.Database, m.Storage)
+}
+
+// ListCertificates returns every certificate record known to Postgres, for
+// the GET /certificates endpoint.
+func (m *Manager) ListCertificates() ([]models.Certificate, error) {
+	var certificates []models.Certificate
+	err := m.Database.Database.Find(&certificates).Error
+	return certificates, err
+}
+
+// RenewCertificate re-issues the certificate for id, archiving the
+// superseded cert in MinIO.
+func (m *Manager) RenewCertificate(ctx context.Context, id uuid.UUID) (*certificate.Certificate, error) {
+	cert, err := certificate.LoadCertificate(ctx, id, &m.Database, m.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if err := cert.Renew(ctx, &m.Database, m.Storage); err != nil {
+		return nil, err
+	}
+	if m.CertCache != nil {
+		m.CertCache.Invalidate(cert.Domains...)
+	}
+	return cert, nil
+}
+
+// RenewCertificateFromCSR renews the certificate for id using a
+// caller-supplied CSR instead of generating a new keypair, so users with
+// HSM-managed keys can rotate certs without ever exporting the private key.
+func (m *Manager) RenewCertificateFromCSR(ctx context.Context, id uuid.UUID, csr *x509.CertificateRequest) (*certificate.Certificate, error) {
+	cert, err := certificate.LoadCertificate(ctx, id, &m.Database, m.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if err := cert.RenewFromCSR(ctx, csr, &m.Database, m.Storage); err != nil {
+		return nil, err
+	}
+	if m.CertCache != nil {
+		m.CertCache.Invalidate(cert.Domains...)
+	}
+	return cert, nil
 }
 
-func (m *Manager) NewCertificate(domains []string, account *account.Account) (*certificate.Certificate, error) {
-	return certificate.NewCertificate(domains, account, m.Storage)
+// RevokeCertificate revokes the certificate for id and archives it under
+// archive/revoked/.
+func (m *Manager) RevokeCertificate(ctx context.Context, id uuid.UUID) error {
+	cert, err := certificate.LoadCertificate(ctx, id, &m.Database, m.Storage)
+	if err != nil {
+		return err
+	}
+	if err := cert.Revoke(ctx, &m.Database, m.Storage); err != nil {
+		return err
+	}
+	if m.CertCache != nil {
+		m.CertCache.Invalidate(cert.Domains...)
+	}
+	return nil
 }
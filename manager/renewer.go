@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/secnex/certmanager/logger"
+	"github.com/secnex/certmanager/models"
+)
+
+// DefaultRenewalWindow is how far ahead of NotAfter a certificate becomes
+// eligible for renewal.
+const DefaultRenewalWindow = 30 * 24 * time.Hour
+
+// Renewer periodically scans certificates in Postgres and renews any whose
+// NotAfter falls within RenewalWindow.
+type Renewer struct {
+	Manager       *Manager
+	RenewalWindow time.Duration
+	ScanInterval  time.Duration
+	stop          chan struct{}
+}
+
+// NewRenewer builds a Renewer with the default 30 day renewal window and an
+// hourly scan interval.
+func NewRenewer(manager *Manager) *Renewer {
+	return &Renewer{
+		Manager:       manager,
+		RenewalWindow: DefaultRenewalWindow,
+		ScanInterval:  time.Hour,
+		stop:          make(chan struct{}),
+	}
+}
+
+// Start runs the scan loop until Stop is called. Call it in its own
+// goroutine.
+func (r *Renewer) Start() {
+	ticker := time.NewTicker(r.ScanInterval)
+	defer ticker.Stop()
+
+	r.scanAndRenew()
+	for {
+		select {
+		case <-ticker.C:
+			r.scanAndRenew()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Stop ends the scan loop started by Start.
+func (r *Renewer) Stop() {
+	close(r.stop)
+}
+
+func (r *Renewer) scanAndRenew() {
+	due, err := r.dueForRenewal()
+	if err != nil {
+		log.Printf("renewer: failed to scan certificates: %v", err)
+		return
+	}
+
+	for _, cert := range due {
+		// Each renewal gets its own request ID so an operator can grep one
+		// ID across the ACME and storage layers for this cert, the same as
+		// they would for an HTTP-triggered renewal.
+		ctx := logger.WithRequestID(context.Background(), uuid.New().String())
+		logger.Printf(ctx, "renewer: renewing certificate %s (expires %s)", cert.ID, cert.NotAfter)
+		if _, err := r.Manager.RenewCertificate(ctx, cert.ID); err != nil {
+			logger.Printf(ctx, "renewer: failed to renew certificate %s: %v", cert.ID, err)
+			if time.Now().After(cert.NotAfter) {
+				r.markExpired(ctx, cert)
+			}
+		}
+	}
+}
+
+// markExpired flags a certificate that has already passed its NotAfter
+// without a successful renewal, so it stops being reported as active and
+// dueForRenewal stops retrying it automatically - an operator has to step
+// in rather than the renewer silently hammering a cert that keeps failing
+// past its expiry. The update is scoped to status = active and the NotAfter
+// this scan saw, so it can't clobber a concurrent renewal (e.g. triggered through the API) that
+// already moved the row on to a fresh NotAfter while this scan was running.
+func (r *Renewer) markExpired(ctx context.Context, cert models.Certificate) {
+	result := r.Manager.Database.Database.
+		Model(&models.Certificate{}).
+		Where("id = ? AND status = ? AND not_after = ?", cert.ID, models.CertificateStatusActive, cert.NotAfter).
+		Update("status", models.CertificateStatusExpired)
+	if result.Error != nil {
+		logger.Printf(ctx, "renewer: failed to mark certificate %s expired: %v", cert.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		logger.Printf(ctx, "renewer: certificate %s no longer active, skipping expired transition", cert.ID)
+		return
+	}
+	logger.Printf(ctx, "renewer: marked certificate %s expired", cert.ID)
+}
+
+func (r *Renewer) dueForRenewal() ([]models.Certificate, error) {
+	var due []models.Certificate
+	cutoff := time.Now().Add(r.RenewalWindow)
+	err := r.Manager.Database.Database.
+		Where("status = ? AND not_after <= ?", models.CertificateStatusActive, cutoff).
+		Find(&due).Error
+	return due, err
+}
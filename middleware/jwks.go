@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// jsonWebKey is the subset of RFC 7517 fields this package understands:
+// RSA keys (kty "RSA") and EC keys (kty "EC") on curve P-256.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwksDocument struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// JWKSCache fetches a JWKS document from a URL and keeps it refreshed in
+// the background, so AuthMiddleware can look up a signing key by kid
+// without hitting the network on every request.
+type JWKSCache struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mu   sync.RWMutex
+	keys map[string]interface{}
+
+	stop chan struct{}
+}
+
+// NewJWKSCache fetches url once to populate the cache, then returns a
+// JWKSCache ready to have Start called on it.
+func NewJWKSCache(url string, refreshInterval time.Duration) (*JWKSCache, error) {
+	cache := &JWKSCache{
+		url:             url,
+		refreshInterval: refreshInterval,
+		client:          http.DefaultClient,
+		keys:            map[string]interface{}{},
+		stop:            make(chan struct{}),
+	}
+	if err := cache.refresh(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Start refreshes the JWKS document every refreshInterval until Stop is
+// called. It also refreshes once immediately, so a cache that was
+// constructed empty after a failed initial fetch (see getJWKSCache) starts
+// retrying right away instead of waiting a full refreshInterval. Call it in
+// its own goroutine.
+func (c *JWKSCache) Start() {
+	if err := c.refresh(); err != nil {
+		log.Printf("middleware: failed to refresh JWKS from %s: %v", c.url, err)
+	}
+
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.refresh(); err != nil {
+				log.Printf("middleware: failed to refresh JWKS from %s: %v", c.url, err)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Stop ends the refresh loop started by Start.
+func (c *JWKSCache) Stop() {
+	close(c.stop)
+}
+
+// Key returns the public key for kid, if the cache has one.
+func (c *JWKSCache) Key(kid string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+func (c *JWKSCache) refresh() error {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS from %s: %w", c.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS from %s: unexpected status %s", c.url, resp.Status)
+	}
+
+	var doc jwksDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("failed to decode JWKS from %s: %w", c.url, err)
+	}
+
+	keys := make(map[string]interface{}, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Kid == "" {
+			continue
+		}
+		key, err := parseJSONWebKey(jwk)
+		if err != nil {
+			// One unsupported or malformed key (e.g. a key type we don't
+			// handle yet) shouldn't keep every other, valid key in the
+			// document from being picked up.
+			log.Printf("middleware: skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+
+	return nil
+}
+
+func parseJSONWebKey(jwk jsonWebKey) (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		return parseRSAPublicKey(jwk)
+	case "EC":
+		return parseECPublicKey(jwk)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func parseRSAPublicKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
+
+func parseECPublicKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	if jwk.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported EC curve %q", jwk.Crv)
+	}
+	xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+	}
+	yBytes, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(xBytes),
+		Y:     new(big.Int).SetBytes(yBytes),
+	}, nil
+}
@@ -10,11 +10,15 @@ import (
 )
 
 type Account struct {
-	ID        uuid.UUID      `gorm:"primaryKey;type:uuid;default:gen_random_uuid()" json:"id"`
-	Email     string         `gorm:"unique;not null" json:"email"`
-	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	ID uuid.UUID `gorm:"primaryKey;type:uuid;default:gen_random_uuid()" json:"id"`
+	// Email and CADirectoryURL are unique together so one deployment can
+	// host the same email against Let's Encrypt production, staging, and a
+	// private Pebble/step-ca instance at the same time.
+	Email          string         `gorm:"not null;uniqueIndex:idx_accounts_email_cadir" json:"email"`
+	CADirectoryURL string         `gorm:"not null;default:'';uniqueIndex:idx_accounts_email_cadir" json:"ca_directory_url"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 func (Account) TableName() string {
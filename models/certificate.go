@@ -1,6 +1,10 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type CertificateType string
 
@@ -9,11 +13,35 @@ const (
 	CertificateTypeCustom      CertificateType = "custom"
 )
 
+// CertificateStatus tracks where a certificate is in the issue/renew/revoke
+// lifecycle.
+type CertificateStatus string
+
+const (
+	CertificateStatusActive   CertificateStatus = "active"
+	CertificateStatusRenewing CertificateStatus = "renewing"
+	CertificateStatusRevoked  CertificateStatus = "revoked"
+	CertificateStatusExpired  CertificateStatus = "expired"
+)
+
 type Certificate struct {
 	ID        uuid.UUID `gorm:"primaryKey;type:uuid;default:gen_random_uuid()" json:"id"`
 	AccountID uuid.UUID `gorm:"not null" json:"account_id"`
 
 	Domains []string `gorm:"not null" json:"domains"`
+	// UnicodeDomains holds the original Unicode label for each entry in
+	// Domains, which stores the ASCII/punycode form sent to ACME.
+	UnicodeDomains []string `json:"unicode_domains"`
+
+	NotAfter  time.Time         `json:"not_after"`
+	Status    CertificateStatus `gorm:"not null;default:active" json:"status"`
+	RevokedAt *time.Time        `json:"revoked_at,omitempty"`
+
+	// ChallengeType and DNSProvider record how this certificate was issued,
+	// so a renewal driven through LoadCertificate solves the same challenge
+	// again instead of always falling back to HTTP-01.
+	ChallengeType string `gorm:"not null;default:http" json:"challenge_type"`
+	DNSProvider   string `json:"dns_provider,omitempty"`
 
 	Account Account `gorm:"foreignKey:AccountID" json:"account"`
 }
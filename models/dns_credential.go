@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DNSCredential stores the configuration an automated DNS-01 provider needs
+// to manage records for an account, scoped per account so multiple domains
+// and CAs can share a deployment without colliding credentials.
+type DNSCredential struct {
+	ID        uuid.UUID         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()" json:"id"`
+	AccountID uuid.UUID         `gorm:"not null;index" json:"account_id"`
+	Provider  string            `gorm:"not null" json:"provider"`
+	Config    map[string]string `gorm:"serializer:json;not null" json:"-"`
+	CreatedAt time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt    `gorm:"index" json:"deleted_at"`
+
+	Account Account `gorm:"foreignKey:AccountID" json:"-"`
+}
+
+func (DNSCredential) TableName() string {
+	return "dns_credentials"
+}
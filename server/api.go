@@ -2,35 +2,61 @@ package server
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 
+	"github.com/go-acme/lego/challenge/tlsalpn01"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/secnex/certmanager/common/certificate"
 	"github.com/secnex/certmanager/database"
+	"github.com/secnex/certmanager/logger"
 	"github.com/secnex/certmanager/manager"
 	"github.com/secnex/certmanager/middleware"
 	"golang.org/x/crypto/acme/autocert"
 )
 
+// certificateCacheSize bounds the in-memory LRU of parsed user-issued
+// certificates kept by the default CertificateResolver.
+const certificateCacheSize = 256
+
+// maxCSRBodyBytes bounds RenewCertificateFromCSR's request body: a PEM CSR
+// is a few KB at most even for an oversized key, so this is generous
+// headroom against a client trying to exhaust memory with a huge body.
+const maxCSRBodyBytes = 64 * 1024
+
 type ApiServer struct {
-	Host      *string
-	Port      *int
-	Databases map[string]*database.Database
-	Manager   *manager.Manager
-	Router    *mux.Router
+	Host                *string
+	Port                *int
+	Databases           map[string]*database.Database
+	Manager             *manager.Manager
+	Router              *mux.Router
+	CertificateResolver CertificateResolver
 }
 
 func NewApiServer(host *string, port *int, manager *manager.Manager) *ApiServer {
 	databases := make(map[string]*database.Database)
 	databases["certmanager"] = database.NewConnection("localhost", 5432, "postgres", "postgres", "cert")
+
+	resolver := NewMinIOCertificateResolver(&manager.Database, manager.Storage, certificateCacheSize)
+	// The manager invalidates this cache on renew/revoke, so the SNI
+	// listener stops serving a stale or revoked certificate as soon as the
+	// renewal/revocation that caused it completes.
+	manager.CertCache = resolver
+
 	return &ApiServer{
-		Host:      host,
-		Port:      port,
-		Databases: databases,
-		Manager:   manager,
-		Router:    mux.NewRouter(),
+		Host:                host,
+		Port:                port,
+		Databases:           databases,
+		Manager:             manager,
+		Router:              mux.NewRouter(),
+		CertificateResolver: resolver,
 	}
 }
 
@@ -44,7 +70,95 @@ func (s *ApiServer) Test(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("This is a test!"))
 }
 
+func (s *ApiServer) ListCertificates(w http.ResponseWriter, r *http.Request) {
+	certificates, err := s.Manager.ListCertificates()
+	if err != nil {
+		http.Error(w, "Failed to list certificates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(certificates)
+}
+
+func (s *ApiServer) RenewCertificate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid certificate id", http.StatusBadRequest)
+		return
+	}
+
+	cert, err := s.Manager.RenewCertificate(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to renew certificate: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cert.Certificate)
+}
+
+// RenewCertificateFromCSR renews a certificate from a caller-supplied CSR
+// (PEM-encoded in the request body) instead of generating a new keypair, so
+// users with HSM-managed keys can rotate certs without ever exporting the
+// private key.
+func (s *ApiServer) RenewCertificateFromCSR(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid certificate id", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSRBodyBytes))
+	if err != nil {
+		http.Error(w, "Failed to read CSR", http.StatusBadRequest)
+		return
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		http.Error(w, "Request body must be a PEM-encoded CSR", http.StatusBadRequest)
+		return
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid CSR: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	cert, err := s.Manager.RenewCertificateFromCSR(r.Context(), id, csr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to renew certificate from CSR: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cert.Certificate)
+}
+
+func (s *ApiServer) RevokeCertificate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid certificate id", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Manager.RevokeCertificate(r.Context(), id); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to revoke certificate: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s *ApiServer) setupRoutes() {
+	// Logging runs for every route, including public ones, and must be
+	// registered on the router itself (not wrapped around it) so
+	// mux.CurrentRoute sees the matched route and AuthMiddleware's context
+	// annotations are visible once the handler chain returns.
+	s.Router.Use(logger.LogHTTPRequest)
+
 	// Public routes (no authentication required)
 	s.Router.HandleFunc("/healthz", s.Healthz).Methods(http.MethodGet)
 
@@ -53,6 +167,11 @@ func (s *ApiServer) setupRoutes() {
 	protected.Use(middleware.AuthMiddleware)
 
 	protected.HandleFunc("/test", s.Test).Methods(http.MethodGet)
+
+	protected.HandleFunc("/certificates", s.ListCertificates).Methods(http.MethodGet)
+	protected.Handle("/certificates/{id}/renew", middleware.RequireScope("certificates:write")(http.HandlerFunc(s.RenewCertificate))).Methods(http.MethodPost)
+	protected.Handle("/certificates/{id}/renew-csr", middleware.RequireScope("certificates:write")(http.HandlerFunc(s.RenewCertificateFromCSR))).Methods(http.MethodPost)
+	protected.Handle("/certificates/{id}/revoke", middleware.RequireScope("certificates:write")(http.HandlerFunc(s.RevokeCertificate))).Methods(http.MethodPost)
 }
 
 func (s *ApiServer) Start() {
@@ -75,16 +194,13 @@ func (s *ApiServer) Start() {
 
 	log.Printf("Starting api server on %s...", addr)
 
-	// Setup routes with selective authentication
+	// Setup routes with selective authentication and access logging
 	s.setupRoutes()
 
-	// Apply logging middleware to all routes
-	handler := middleware.LogHTTPRequest(s.Router)
-
 	if https {
-		s.StartHTTPS(handler, addr, domain)
+		s.StartHTTPS(s.Router, addr, domain)
 	} else {
-		s.StartHTTP(handler)
+		s.StartHTTP(s.Router)
 	}
 }
 
@@ -97,6 +213,13 @@ func (s *ApiServer) StartHTTP(handler http.Handler) {
 	log.Fatal(server.ListenAndServe())
 }
 
+// StartHTTPS owns :443. Because that's also where TLS-ALPN-01 challenges
+// and user-issued certs need to be served, GetCertificate dispatches each
+// handshake instead of handing the listener to autocert outright: an
+// acme-tls/1 handshake is routed to the in-flight challenge, a handshake
+// for a domain this service has issued a certificate for is routed to
+// CertificateResolver, and everything else falls back to autocert's own
+// API certificate.
 func (s *ApiServer) StartHTTPS(handler http.Handler, addr string, domain string) {
 	m := &autocert.Manager{
 		Prompt:     autocert.AcceptTOS,
@@ -107,7 +230,8 @@ func (s *ApiServer) StartHTTPS(handler http.Handler, addr string, domain string)
 	server := &http.Server{
 		Addr: addr,
 		TLSConfig: &tls.Config{
-			GetCertificate: m.GetCertificate,
+			GetCertificate: s.getCertificate(m),
+			NextProtos:     []string{tlsalpn01.ACMETLS1Protocol, "h2", "http/1.1"},
 		},
 		Handler: handler,
 	}
@@ -118,3 +242,26 @@ func (s *ApiServer) StartHTTPS(handler http.Handler, addr string, domain string)
 
 	log.Fatal(server.ListenAndServeTLS("", ""))
 }
+
+// getCertificate builds the tls.Config.GetCertificate dispatcher described
+// on StartHTTPS.
+func (s *ApiServer) getCertificate(m *autocert.Manager) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		for _, proto := range hello.SupportedProtos {
+			if proto == tlsalpn01.ACMETLS1Protocol {
+				if cert, ok := certificate.DefaultTLSALPNStore.ChallengeCertificate(hello.ServerName); ok {
+					return cert, nil
+				}
+				return nil, fmt.Errorf("no tls-alpn-01 challenge in flight for %s", hello.ServerName)
+			}
+		}
+
+		if s.CertificateResolver != nil {
+			if cert, err := s.CertificateResolver.Resolve(hello.ServerName); err == nil {
+				return cert, nil
+			}
+		}
+
+		return m.GetCertificate(hello)
+	}
+}
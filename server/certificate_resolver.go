@@ -0,0 +1,136 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+
+	"github.com/secnex/certmanager/database"
+	"github.com/secnex/certmanager/models"
+	"github.com/secnex/certmanager/store"
+)
+
+// CertificateResolver looks up the *tls.Certificate to serve for a given
+// SNI hostname, so user-issued certs can be served by the same listener as
+// the autocert-managed API cert.
+type CertificateResolver interface {
+	Resolve(serverName string) (*tls.Certificate, error)
+}
+
+// lruCertificateCache is a small fixed-size, least-recently-used cache of
+// parsed certificates, so a hot domain doesn't pay for a MinIO round trip
+// and PEM/key parsing on every handshake.
+type lruCertificateCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    []string
+	entries  map[string]*tls.Certificate
+}
+
+func newLRUCertificateCache(capacity int) *lruCertificateCache {
+	return &lruCertificateCache{capacity: capacity, entries: make(map[string]*tls.Certificate)}
+}
+
+func (c *lruCertificateCache) get(key string) (*tls.Certificate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cert, ok := c.entries[key]
+	if ok {
+		c.touch(key)
+	}
+	return cert, ok
+}
+
+func (c *lruCertificateCache) put(key string, cert *tls.Certificate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.capacity {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = cert
+	c.touch(key)
+}
+
+func (c *lruCertificateCache) touch(key string) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, key)
+}
+
+func (c *lruCertificateCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		return
+	}
+	delete(c.entries, key)
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// MinIOCertificateResolver resolves certificates issued through this
+// service: Postgres maps an SNI hostname to a certificate ID, and MinIO
+// holds the PEM bundle and key for that ID. Lookups are cached in-memory.
+type MinIOCertificateResolver struct {
+	Database *database.Database
+	Storage  *store.Storage
+	cache    *lruCertificateCache
+}
+
+// NewMinIOCertificateResolver returns a resolver backed by an LRU cache
+// holding up to cacheSize parsed certificates.
+func NewMinIOCertificateResolver(db *database.Database, storage *store.Storage, cacheSize int) *MinIOCertificateResolver {
+	return &MinIOCertificateResolver{
+		Database: db,
+		Storage:  storage,
+		cache:    newLRUCertificateCache(cacheSize),
+	}
+}
+
+// Invalidate evicts domains from the cache, so a renewal or revocation is
+// reflected on the next handshake instead of waiting for an eviction that,
+// for a deployment smaller than the cache's capacity, may never happen.
+func (r *MinIOCertificateResolver) Invalidate(domains ...string) {
+	for _, domain := range domains {
+		r.cache.delete(domain)
+	}
+}
+
+func (r *MinIOCertificateResolver) Resolve(serverName string) (*tls.Certificate, error) {
+	if cert, ok := r.cache.get(serverName); ok {
+		return cert, nil
+	}
+
+	var row models.Certificate
+	if err := r.Database.Database.Where("? = ANY(domains) AND status = ?", serverName, models.CertificateStatusActive).First(&row).Error; err != nil {
+		return nil, fmt.Errorf("no active certificate found for %s: %w", serverName, err)
+	}
+
+	certPEM, err := r.Storage.Read(row.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate for %s: %w", serverName, err)
+	}
+
+	keyPEM, err := r.Storage.ReadCertificateKey(row.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate key for %s: %w", serverName, err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate for %s: %w", serverName, err)
+	}
+
+	r.cache.put(serverName, &cert)
+	return &cert, nil
+}
@@ -6,6 +6,7 @@ import (
 	"crypto"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 	"io"
 
 	"github.com/go-acme/lego/certificate"
@@ -53,7 +54,40 @@ func (s *Storage) Save(key string, data []byte) error {
 }
 
 func (s *Storage) SaveCertificate(id string, cert *certificate.Resource) error {
-	return s.Save(id, cert.Certificate)
+	if err := s.Save(id, cert.Certificate); err != nil {
+		return err
+	}
+	return s.Save(id+".key", cert.PrivateKey)
+}
+
+// SaveCertificateBody saves only the certificate PEM under id, leaving any
+// existing id+".key" object untouched. Use this instead of SaveCertificate
+// when the resource's PrivateKey field is empty because the key never left
+// the caller, e.g. a CSR-based renewal - SaveCertificate would otherwise
+// overwrite the stored key with zero bytes.
+func (s *Storage) SaveCertificateBody(id string, certPEM []byte) error {
+	return s.Save(id, certPEM)
+}
+
+// ReadCertificateKey reads back the private key saved alongside a
+// certificate issued through SaveCertificate.
+func (s *Storage) ReadCertificateKey(id string) ([]byte, error) {
+	return s.Read(id + ".key")
+}
+
+// ArchiveCertificate moves a superseded certificate out of the way on
+// renewal, keeping it around under its serial number for audit purposes.
+func (s *Storage) ArchiveCertificate(id string, serial string, data []byte) error {
+	return s.Save(fmt.Sprintf("certs/archive/%s/%s.pem", id, serial), data)
+}
+
+// ArchiveRevokedCertificate moves a revoked certificate to the
+// archive/revoked/ prefix instead of leaving it under its live key.
+func (s *Storage) ArchiveRevokedCertificate(id string, serial string, data []byte) error {
+	if err := s.Save(fmt.Sprintf("certs/archive/revoked/%s/%s.pem", id, serial), data); err != nil {
+		return err
+	}
+	return s.Client.RemoveObject(s.Context, s.Bucket, id, minio.RemoveObjectOptions{})
 }
 
 func (s *Storage) ReadPrivateKey(id string) (crypto.PrivateKey, error) {
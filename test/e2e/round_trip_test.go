@@ -0,0 +1,163 @@
+package e2e
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/secnex/certmanager/common/account"
+	"github.com/secnex/certmanager/common/certificate"
+)
+
+// TestIssueRenewRevokeRoundTrip exercises the full issuance pipeline
+// against ephemeral Pebble, Postgres, and MinIO instances: HTTP-01, DNS-01,
+// and TLS-ALPN-01 issuance, a renewal, a revocation (including a
+// subdomain), and a non-ASCII domain.
+func TestIssueRenewRevokeRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	ctx := context.Background()
+	h := NewHarness(ctx, t)
+	defer h.Close(ctx)
+	waitForReady()
+
+	acct, err := account.NewAccountWithDirectory(ctx, "e2e@example.com", h.DirectoryURL, h.Database, h.Storage)
+	if err != nil {
+		t.Fatalf("failed to create account against Pebble: %v", err)
+	}
+
+	t.Run("HTTP-01 happy path", func(t *testing.T) {
+		cert, err := certificate.NewCertificateWithConfig(
+			ctx,
+			[]string{"http01.example.test"},
+			acct, h.Database, h.Storage,
+			&certificate.CertificateConfig{ChallengeType: certificate.ChallengeTypeHTTP},
+		)
+		if err != nil {
+			t.Fatalf("failed to issue HTTP-01 certificate: %v", err)
+		}
+
+		if err := cert.Renew(ctx, h.Database, h.Storage); err != nil {
+			t.Fatalf("failed to renew certificate: %v", err)
+		}
+		assertArchived(ctx, t, h, cert.ID.String())
+	})
+
+	t.Run("DNS-01 subdomain revocation", func(t *testing.T) {
+		cert, err := certificate.NewCertificateWithConfig(
+			ctx,
+			[]string{"sub.dns01.example.test"},
+			acct, h.Database, h.Storage,
+			&certificate.CertificateConfig{ChallengeType: certificate.ChallengeTypeDNS, DNSProvider: "manual"},
+		)
+		if err != nil {
+			t.Fatalf("failed to issue DNS-01 certificate: %v", err)
+		}
+
+		if err := cert.Revoke(ctx, h.Database, h.Storage); err != nil {
+			t.Fatalf("failed to revoke certificate: %v", err)
+		}
+		assertRevokedArchived(ctx, t, h, cert.ID.String())
+	})
+
+	t.Run("TLS-ALPN-01 happy path", func(t *testing.T) {
+		_, err := certificate.NewCertificateWithConfig(
+			ctx,
+			[]string{"tlsalpn01.example.test"},
+			acct, h.Database, h.Storage,
+			&certificate.CertificateConfig{ChallengeType: certificate.ChallengeTypeTLSALPN},
+		)
+		if err != nil {
+			t.Fatalf("failed to issue TLS-ALPN-01 certificate: %v", err)
+		}
+	})
+
+	t.Run("CSR renewal keeps the caller's key", func(t *testing.T) {
+		cert, err := certificate.NewCertificateWithConfig(
+			ctx,
+			[]string{"csr.example.test"},
+			acct, h.Database, h.Storage,
+			&certificate.CertificateConfig{ChallengeType: certificate.ChallengeTypeHTTP},
+		)
+		if err != nil {
+			t.Fatalf("failed to issue certificate: %v", err)
+		}
+
+		csr := generateCSR(t, "csr.example.test")
+		if err := cert.RenewFromCSR(ctx, csr, h.Database, h.Storage); err != nil {
+			t.Fatalf("failed to renew certificate from CSR: %v", err)
+		}
+		assertArchived(ctx, t, h, cert.ID.String())
+
+		if _, err := h.Storage.ReadCertificateKey(cert.ID.String()); err != nil {
+			t.Fatalf("expected the original key to still be readable after a CSR renewal: %v", err)
+		}
+	})
+
+	t.Run("non-ASCII domain", func(t *testing.T) {
+		cert, err := certificate.NewCertificateWithConfig(
+			ctx,
+			[]string{"münchen.example.test"},
+			acct, h.Database, h.Storage,
+			&certificate.CertificateConfig{ChallengeType: certificate.ChallengeTypeHTTP},
+		)
+		if err != nil {
+			t.Fatalf("failed to issue certificate for non-ASCII domain: %v", err)
+		}
+
+		if len(cert.Domains) != 1 || cert.Domains[0] != "xn--mnchen-3ya.example.test" {
+			t.Fatalf("expected punycode domain to be stored, got %v", cert.Domains)
+		}
+		if len(cert.UnicodeDomains) != 1 || cert.UnicodeDomains[0] != "münchen.example.test" {
+			t.Fatalf("expected original unicode domain to be preserved, got %v", cert.UnicodeDomains)
+		}
+	})
+}
+
+func assertArchived(ctx context.Context, t *testing.T, h *Harness, certID string) {
+	t.Helper()
+	prefix := "certs/archive/" + certID + "/"
+	if !h.objectExistsWithPrefix(ctx, t, prefix) {
+		t.Fatalf("expected an archived certificate object under %s", prefix)
+	}
+}
+
+func assertRevokedArchived(ctx context.Context, t *testing.T, h *Harness, certID string) {
+	t.Helper()
+	prefix := "certs/archive/revoked/" + certID + "/"
+	if !h.objectExistsWithPrefix(ctx, t, prefix) {
+		t.Fatalf("expected a revoked certificate object under %s", prefix)
+	}
+}
+
+// generateCSR builds a CSR for domain signed by a key the caller (not the
+// certificate issuance pipeline) holds, simulating an HSM-held key that
+// never leaves the caller.
+func generateCSR(t *testing.T, domain string) *x509.CertificateRequest {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate CSR key: %v", err)
+	}
+
+	template := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domain},
+		DNSNames: []string{domain},
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		t.Fatalf("failed to create CSR: %v", err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		t.Fatalf("failed to parse generated CSR: %v", err)
+	}
+	return csr
+}